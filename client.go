@@ -1,10 +1,393 @@
 package rcon
 
 import (
+	"bytes"
+	"context"
+	"log"
 	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/pkg/errors"
+	"go.uber.org/atomic"
+
+	"github.com/crossworth/battleye-rcon/events"
+)
+
+const (
+	// clientKeepAliveInterval is how often the client pings the server with
+	// an empty CommandPacketType when idle, the documentation talks about
+	// 45 seconds, we ping once this whole interval has passed without activity
+	clientKeepAliveInterval = 45 * time.Second
+
+	// clientLoginTimeout is how long NewClient waits for the login response
+	clientLoginTimeout = 5 * time.Second
 )
 
-type Client interface {
-	Close() error
-	WriteTo(p []byte, addr net.Addr) (int, error)
+// pendingCommand tracks an Exec call waiting for its (possibly fragmented)
+// response
+type pendingCommand struct {
+	response chan string
+}
+
+// Client is a BattlEye RCon client, it dials a RCon server over UDP,
+// performs the login handshake and allows executing commands and
+// receiving server broadcasts
+type Client struct {
+	conn      *net.UDPConn
+	Logger    Logger
+	seqNumber atomic.Uint32
+
+	mu              sync.Mutex
+	onServerMessage func(msg string)
+	eventHandler    func(event events.Event)
+	pending         map[uint8]*pendingCommand
+	fragmentBuffers *cache.Cache
+
+	activity  chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewClient dials a BattlEye RCon server at host:port and performs the login
+// handshake using password, it will not return until the login completes
+func NewClient(host string, port int, password string) (*Client, error) {
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.ParseIP(host), Port: port})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not dial rcon server")
+	}
+
+	c := &Client{
+		conn:            conn,
+		Logger:          log.New(os.Stdout, "", log.LstdFlags),
+		pending:         make(map[uint8]*pendingCommand),
+		fragmentBuffers: cache.New(cache.DefaultExpiration, 1*time.Minute),
+		activity:        make(chan struct{}, 1),
+		done:            make(chan struct{}),
+	}
+
+	if err := c.login(password); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	go c.readLoop()
+	go c.keepAliveLoop()
+
+	return c, nil
+}
+
+func (c *Client) login(password string) error {
+	if _, err := c.conn.Write(MakeLoginRequestPacket(password)); err != nil {
+		return errors.Wrap(err, "could not write login packet")
+	}
+
+	buf := make([]byte, 4096)
+	_ = c.conn.SetReadDeadline(time.Now().Add(clientLoginTimeout))
+	n, err := c.conn.Read(buf)
+	_ = c.conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return errors.Wrap(err, "could not read login response")
+	}
+
+	input := bytes.NewReader(buf[:n])
+	if _, err := ParseHeader(input); err != nil {
+		return errors.Wrap(err, "could not read login response header")
+	}
+
+	packetType, err := ParsePacketType(input)
+	if err != nil {
+		return errors.Wrap(err, "could not read login response packet type")
+	}
+
+	if packetType != LoginPacketType {
+		return errors.New("unexpected packet type on login response")
+	}
+
+	responseType, err := ParseSequenceNumber(input)
+	if err != nil {
+		return errors.Wrap(err, "could not read login response type")
+	}
+
+	if LoginResponseType(responseType) != LoginSuccessful {
+		return errors.New("login failed, wrong password")
+	}
+
+	return nil
+}
+
+// NextSequenceNumber returns the next sequence number to be used on a command
+// request
+func (c *Client) NextSequenceNumber() uint8 {
+	number := c.seqNumber.Load()
+
+	newNumber := c.seqNumber.Inc()
+
+	if newNumber > 255 {
+		c.seqNumber.Store(0)
+	}
+
+	return uint8(number)
+}
+
+// OnServerMessage registers a callback invoked for every ServerMessagePacketType
+// broadcast received from the server
+func (c *Client) OnServerMessage(handle func(msg string)) {
+	c.mu.Lock()
+	c.onServerMessage = handle
+	c.mu.Unlock()
+}
+
+// OnEvent registers a callback invoked with the parsed events.Event for every
+// ServerMessagePacketType broadcast received from the server, so bots built
+// on top of this client can react to well-known BattlEye events without
+// regex-scraping the raw message themselves
+func (c *Client) OnEvent(handle func(event events.Event)) {
+	c.mu.Lock()
+	c.eventHandler = handle
+	c.mu.Unlock()
+}
+
+// Exec sends command to the server and blocks until its (possibly fragmented)
+// response arrives or ctx is done
+func (c *Client) Exec(ctx context.Context, command string) (string, error) {
+	seq := c.NextSequenceNumber()
+
+	p := &pendingCommand{response: make(chan string, 1)}
+
+	c.mu.Lock()
+	c.pending[seq] = p
+	c.mu.Unlock()
+
+	if _, err := c.conn.Write(MakeCommandRequestPacket(seq, command)); err != nil {
+		c.mu.Lock()
+		delete(c.pending, seq)
+		c.mu.Unlock()
+		return "", errors.Wrap(err, "could not write command packet")
+	}
+
+	c.notifyActivity()
+
+	select {
+	case response := <-p.response:
+		return response, nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, seq)
+		c.mu.Unlock()
+		return "", ctx.Err()
+	case <-c.done:
+		return "", errors.New("client is closed")
+	}
+}
+
+// Close stops the keep-alive and read loops and closes the underlying
+// connection
+func (c *Client) Close() error {
+	var err error
+
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.conn.Close()
+	})
+
+	return err
+}
+
+func (c *Client) notifyActivity() {
+	select {
+	case c.activity <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Client) keepAliveLoop() {
+	timer := time.NewTimer(clientKeepAliveInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-c.activity:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(clientKeepAliveInterval)
+		case <-timer.C:
+			seq := c.NextSequenceNumber()
+			if _, err := c.conn.Write(MakeCommandRequestPacket(seq, "")); err != nil {
+				c.Logger.Printf("could not send keep-alive, %v\n", err)
+			}
+			timer.Reset(clientKeepAliveInterval)
+		}
+	}
+}
+
+func (c *Client) readLoop() {
+	buf := make([]byte, 4096)
+
+	for {
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			select {
+			case <-c.done:
+				return
+			default:
+				c.Logger.Printf("error reading packet, %v\n", err)
+				continue
+			}
+		}
+
+		c.handlePacket(buf[:n])
+	}
+}
+
+func (c *Client) handlePacket(data []byte) {
+	input := bytes.NewReader(data)
+
+	packetHeader, err := ParseHeader(input)
+	if err != nil {
+		c.Logger.Printf("error reading packet header, %v\n", err)
+		return
+	}
+
+	// we skip 6 bytes of packet header (the check sum uses the 0xff at the header)
+	if !VerifyChecksum(data[6:], packetHeader.Checksum) {
+		c.Logger.Printf("wrong packet Checksum, expected %d, got %d\n", NewChecksum(data[6:]), packetHeader.Checksum)
+		return
+	}
+
+	packetType, err := ParsePacketType(input)
+	if err != nil {
+		c.Logger.Printf("error reading packet type, %v\n", err)
+		return
+	}
+
+	switch packetType {
+	case CommandPacketType:
+		c.handleCommandResponse(input, data)
+	case ServerMessagePacketType:
+		c.handleServerMessage(input)
+	}
+}
+
+func (c *Client) handleCommandResponse(input *bytes.Reader, data []byte) {
+	seq, err := ParseSequenceNumber(input)
+	if err != nil {
+		c.Logger.Printf("error reading sequence number, %v\n", err)
+		return
+	}
+
+	// a keep-alive ack has no payload, there is nothing to deliver
+	if input.Len() == 0 {
+		return
+	}
+
+	var command string
+
+	if data[len(data)-input.Len()] == byte(FragmentationPacketType) {
+		_, _ = ParsePacketType(input)
+
+		fragHeader, err := ParseFragmentHeader(input)
+		if err != nil {
+			c.Logger.Printf("error reading fragment header, %v\n", err)
+			return
+		}
+
+		chunk, err := ParseCommand(input)
+		if err != nil {
+			c.Logger.Printf("error reading fragment chunk, %v\n", err)
+			return
+		}
+
+		assembled, complete := c.bufferFragment(seq, fragHeader, chunk)
+		if !complete {
+			return
+		}
+
+		command = assembled
+	} else {
+		command, err = ParseCommand(input)
+		if err != nil {
+			c.Logger.Printf("error reading command response, %v\n", err)
+			return
+		}
+	}
+
+	c.mu.Lock()
+	p, found := c.pending[seq]
+	delete(c.pending, seq)
+	c.mu.Unlock()
+
+	if found {
+		p.response <- command
+	}
+}
+
+// bufferFragment stores a single fragment of a fragmented CommandPacketType
+// response, keyed by command sequence number. Fragments expire after
+// fragmentTimeout so a response the server never finished sending cannot
+// linger and corrupt an unrelated response that later reuses the same
+// sequence number. It returns the reassembled command and true once every
+// fragment described by fragHeader has arrived
+func (c *Client) bufferFragment(seq uint8, fragHeader FragmentHeader, chunk string) (string, bool) {
+	key := strconv.Itoa(int(seq))
+
+	var fb *fragmentBuffer
+	if existing, found := c.fragmentBuffers.Get(key); found {
+		fb = existing.(*fragmentBuffer)
+	} else {
+		fb = &fragmentBuffer{total: fragHeader.Total, chunks: make(map[uint8][]byte)}
+	}
+
+	fb.chunks[fragHeader.Index] = []byte(chunk)
+
+	if len(fb.chunks) < int(fb.total) {
+		c.fragmentBuffers.Set(key, fb, fragmentTimeout)
+		return "", false
+	}
+
+	c.fragmentBuffers.Delete(key)
+
+	var full bytes.Buffer
+	for i := uint8(0); i < fb.total; i++ {
+		full.Write(fb.chunks[i])
+	}
+
+	return full.String(), true
+}
+
+func (c *Client) handleServerMessage(input *bytes.Reader) {
+	seq, err := ParseSequenceNumber(input)
+	if err != nil {
+		c.Logger.Printf("error reading sequence number of ServerMessagePacketType, %v\n", err)
+		return
+	}
+
+	message, err := ParseCommand(input)
+	if err != nil {
+		c.Logger.Printf("error reading server message, %v\n", err)
+		return
+	}
+
+	if _, err := c.conn.Write(MakeServerMessagePacket(seq, nil)); err != nil {
+		c.Logger.Printf("could not acknowledge server message, %v\n", err)
+	}
+
+	c.mu.Lock()
+	onServerMessage := c.onServerMessage
+	eventHandler := c.eventHandler
+	c.mu.Unlock()
+
+	if onServerMessage != nil {
+		onServerMessage(message)
+	}
+
+	if eventHandler != nil {
+		eventHandler(events.Parse(message))
+	}
 }
@@ -0,0 +1,181 @@
+package rcon
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+
+	"github.com/crossworth/battleye-rcon/events"
+)
+
+func newTestClient() *Client {
+	return &Client{
+		Logger:          log.New(io.Discard, "", 0),
+		pending:         make(map[uint8]*pendingCommand),
+		fragmentBuffers: cache.New(cache.DefaultExpiration, 1*time.Minute),
+		activity:        make(chan struct{}, 1),
+		done:            make(chan struct{}),
+	}
+}
+
+func TestClientBufferFragment(t *testing.T) {
+	c := newTestClient()
+
+	if _, complete := c.bufferFragment(5, FragmentHeader{Total: 2, Index: 0}, "AAAA"); complete {
+		t.Fatal("expected incomplete after the first of two fragments")
+	}
+
+	command, complete := c.bufferFragment(5, FragmentHeader{Total: 2, Index: 1}, "BBBB")
+	if !complete {
+		t.Fatal("expected complete after the second of two fragments")
+	}
+
+	if command != "AAAABBBB" {
+		t.Fatalf("expected reassembled command %q, got %q", "AAAABBBB", command)
+	}
+}
+
+func TestClientFragmentBuffersExpireOrphanedFragments(t *testing.T) {
+	c := newTestClient()
+
+	// simulate a response that was never finished: only the first of two
+	// fragments ever arrived, with a short TTL so we don't have to wait
+	// fragmentTimeout out in the test
+	c.fragmentBuffers.Set("5", &fragmentBuffer{total: 2, chunks: map[uint8][]byte{0: []byte("AAAA")}}, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	if _, found := c.fragmentBuffers.Get("5"); found {
+		t.Fatal("expected the orphaned fragment buffer to expire instead of lingering across a sequence number wraparound")
+	}
+
+	command, complete := c.bufferFragment(5, FragmentHeader{Total: 2, Index: 1}, "BBBB")
+	if complete && command == "AAAABBBB" {
+		t.Fatal("expected the expired fragment to not be mixed into an unrelated response reusing the same sequence number")
+	}
+}
+
+func TestClientOnEventParsesServerMessages(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serverConn.Close()
+
+	clientConn, err := net.DialUDP("udp", nil, serverConn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clientConn.Close()
+
+	c := newTestClient()
+	c.conn = clientConn
+
+	var got events.Event
+	c.OnEvent(func(event events.Event) {
+		got = event
+	})
+
+	c.handleServerMessage(bytes.NewReader(append([]byte{3}, []byte("Player #0 Foo disconnected")...)))
+
+	if got != (events.PlayerDisconnected{ID: 0, Name: "Foo"}) {
+		t.Fatalf("expected a parsed PlayerDisconnected event, got %#v", got)
+	}
+}
+
+// TestNewClientLoginAndExec drives NewClient through the login handshake and
+// a round-tripped Exec call against a fake UDP peer standing in for the rcon
+// server.
+func TestNewClientLoginAndExec(t *testing.T) {
+	serverConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serverConn.Close()
+
+	go func() {
+		buf := make([]byte, 4096)
+
+		// login request
+		n, clientAddr, err := serverConn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		input := bytes.NewReader(buf[:n])
+		_, _ = ParseHeader(input)
+		_, _ = ParsePacketType(input)
+		if password, err := ParseCommand(input); err != nil || password != "secret" {
+			return
+		}
+
+		if _, err := serverConn.WriteToUDP(MakeLoginResponsePacket(LoginSuccessful), clientAddr); err != nil {
+			return
+		}
+
+		// command request
+		n, clientAddr, err = serverConn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		input = bytes.NewReader(buf[:n])
+		_, _ = ParseHeader(input)
+		_, _ = ParsePacketType(input)
+		seq, err := ParseSequenceNumber(input)
+		if err != nil {
+			return
+		}
+
+		_, _ = serverConn.WriteToUDP(MakeCommandResponsePacket(seq, []byte("pong")), clientAddr)
+	}()
+
+	serverAddr := serverConn.LocalAddr().(*net.UDPAddr)
+
+	c, err := NewClient(serverAddr.IP.String(), serverAddr.Port, "secret")
+	if err != nil {
+		t.Fatalf("expected login to succeed, got %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	response, err := c.Exec(ctx, "hello")
+	if err != nil {
+		t.Fatalf("expected Exec to succeed, got %v", err)
+	}
+
+	if response != "pong" {
+		t.Fatalf("expected response %q, got %q", "pong", response)
+	}
+}
+
+func TestClientOnServerMessageIsSafeForConcurrentUse(t *testing.T) {
+	c := newTestClient()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+			c.OnServerMessage(func(msg string) {})
+		}()
+
+		go func() {
+			defer wg.Done()
+			c.mu.Lock()
+			_ = c.onServerMessage
+			c.mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,150 @@
+// Package events parses the well-known BattlEye RCon server event messages
+// (the text carried by a ServerMessagePacketType broadcast) into typed
+// structs, so callers don't have to regex-scrape the raw log lines
+// themselves.
+//
+// Reference:
+// https://www.battleye.com/downloads/BERConProtocol.txt
+package events
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// Event is implemented by every parsed BattlEye server event
+type Event interface {
+	// EventName returns a short, stable name for the event kind, e.g. "PlayerConnected"
+	EventName() string
+}
+
+// PlayerConnected is emitted when a player joins the server
+type PlayerConnected struct {
+	ID   int
+	Name string
+	IP   string
+	Port string
+	GUID string
+}
+
+// EventName returns "PlayerConnected"
+func (PlayerConnected) EventName() string { return "PlayerConnected" }
+
+// PlayerDisconnected is emitted when a player leaves the server
+type PlayerDisconnected struct {
+	ID   int
+	Name string
+}
+
+// EventName returns "PlayerDisconnected"
+func (PlayerDisconnected) EventName() string { return "PlayerDisconnected" }
+
+// GUIDVerified is emitted once BattlEye finishes verifying a player's GUID
+type GUIDVerified struct {
+	ID   int
+	Name string
+	GUID string
+}
+
+// EventName returns "GUIDVerified"
+func (GUIDVerified) EventName() string { return "GUIDVerified" }
+
+// PlayerKicked is emitted when a player is kicked, either by BattlEye itself
+// or by an admin
+type PlayerKicked struct {
+	ID     int
+	Name   string
+	Reason string
+}
+
+// EventName returns "PlayerKicked"
+func (PlayerKicked) EventName() string { return "PlayerKicked" }
+
+// AdminLogin is emitted when an RCon client successfully authenticates
+type AdminLogin struct {
+	IP   string
+	Port string
+}
+
+// EventName returns "AdminLogin"
+func (AdminLogin) EventName() string { return "AdminLogin" }
+
+// ChatMessage is emitted for every in-game chat message relayed by the server
+type ChatMessage struct {
+	Channel string
+	Sender  string
+	Text    string
+}
+
+// EventName returns "ChatMessage"
+func (ChatMessage) EventName() string { return "ChatMessage" }
+
+// FilterHit is emitted when a player triggers one of the server's script or
+// BattlEye filters
+type FilterHit struct {
+	ID     int
+	Name   string
+	Filter string
+	Detail string
+}
+
+// EventName returns "FilterHit"
+func (FilterHit) EventName() string { return "FilterHit" }
+
+// Unknown is returned by Parse when line did not match any known event format
+type Unknown struct {
+	Raw string
+}
+
+// EventName returns "Unknown"
+func (Unknown) EventName() string { return "Unknown" }
+
+var (
+	playerConnectedRegexp    = regexp.MustCompile(`^Player #(\d+) (.+) \(([\d.]+):(\d+)\) connected$`)
+	playerDisconnectedRegexp = regexp.MustCompile(`^Player #(\d+) (.+) disconnected$`)
+	guidVerifiedRegexp       = regexp.MustCompile(`^Verified GUID \(([0-9a-f]+)\) of player #(\d+) (.+)$`)
+	filterHitRegexp          = regexp.MustCompile(`^Player #(\d+) (.+) has been kicked by BattlEye: (.+?) \((.+)\)$`)
+	playerKickedRegexp       = regexp.MustCompile(`^Player #(\d+) (.+) kicked off by BattlEye: (.+)$`)
+	adminLoginRegexp         = regexp.MustCompile(`^RCon admin #\d+: \(([\d.]+):(\d+)\) logged in$`)
+	chatMessageRegexp        = regexp.MustCompile(`^\((\w+)\) (.+): (.+)$`)
+)
+
+// Parse tries to recognize line as one of the well-known BattlEye server
+// event messages. It always returns an Event, falling back to Unknown when
+// line does not match any known format.
+func Parse(line string) Event {
+	if m := playerConnectedRegexp.FindStringSubmatch(line); m != nil {
+		id, _ := strconv.Atoi(m[1])
+		return PlayerConnected{ID: id, Name: m[2], IP: m[3], Port: m[4]}
+	}
+
+	if m := guidVerifiedRegexp.FindStringSubmatch(line); m != nil {
+		id, _ := strconv.Atoi(m[2])
+		return GUIDVerified{ID: id, Name: m[3], GUID: m[1]}
+	}
+
+	if m := filterHitRegexp.FindStringSubmatch(line); m != nil {
+		id, _ := strconv.Atoi(m[1])
+		return FilterHit{ID: id, Name: m[2], Filter: m[3], Detail: m[4]}
+	}
+
+	if m := playerKickedRegexp.FindStringSubmatch(line); m != nil {
+		id, _ := strconv.Atoi(m[1])
+		return PlayerKicked{ID: id, Name: m[2], Reason: m[3]}
+	}
+
+	if m := playerDisconnectedRegexp.FindStringSubmatch(line); m != nil {
+		id, _ := strconv.Atoi(m[1])
+		return PlayerDisconnected{ID: id, Name: m[2]}
+	}
+
+	if m := adminLoginRegexp.FindStringSubmatch(line); m != nil {
+		return AdminLogin{IP: m[1], Port: m[2]}
+	}
+
+	if m := chatMessageRegexp.FindStringSubmatch(line); m != nil {
+		return ChatMessage{Channel: m[1], Sender: m[2], Text: m[3]}
+	}
+
+	return Unknown{Raw: line}
+}
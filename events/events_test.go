@@ -0,0 +1,61 @@
+package events
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want Event
+	}{
+		{
+			name: "player connected",
+			line: "Player #0 Foo (127.0.0.1:2304) connected",
+			want: PlayerConnected{ID: 0, Name: "Foo", IP: "127.0.0.1", Port: "2304"},
+		},
+		{
+			name: "player disconnected",
+			line: "Player #0 Foo disconnected",
+			want: PlayerDisconnected{ID: 0, Name: "Foo"},
+		},
+		{
+			name: "guid verified",
+			line: "Verified GUID (deadbeefdeadbeefdeadbeefdeadbeef) of player #0 Foo",
+			want: GUIDVerified{ID: 0, Name: "Foo", GUID: "deadbeefdeadbeefdeadbeefdeadbeef"},
+		},
+		{
+			name: "player kicked by battleye",
+			line: "Player #0 Foo kicked off by BattlEye: Client not responding",
+			want: PlayerKicked{ID: 0, Name: "Foo", Reason: "Client not responding"},
+		},
+		{
+			name: "filter hit",
+			line: "Player #0 Foo has been kicked by BattlEye: Script Restriction #0 (#0 \"fn_example\")",
+			want: FilterHit{ID: 0, Name: "Foo", Filter: "Script Restriction #0", Detail: "#0 \"fn_example\""},
+		},
+		{
+			name: "admin login",
+			line: "RCon admin #0: (127.0.0.1:2305) logged in",
+			want: AdminLogin{IP: "127.0.0.1", Port: "2305"},
+		},
+		{
+			name: "chat message",
+			line: "(Side) Foo: hello everyone",
+			want: ChatMessage{Channel: "Side", Sender: "Foo", Text: "hello everyone"},
+		},
+		{
+			name: "unknown",
+			line: "this is not a known event",
+			want: Unknown{Raw: "this is not a known event"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.line)
+			if got != tt.want {
+				t.Fatalf("Parse(%q) = %#v, want %#v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
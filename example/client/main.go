@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	rcon "github.com/crossworth/battleye-rcon"
+)
+
+func main() {
+	client, err := rcon.NewClient("127.0.0.1", 2301, "test")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	client.OnServerMessage(func(msg string) {
+		fmt.Println("server message:", msg)
+	})
+
+	response, err := client.Exec(context.Background(), "players")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("response:", response)
+
+	select {}
+}
@@ -9,14 +9,16 @@ import (
 )
 
 func main() {
-	server := rcon.NewRCON("", 2301, "test")
+	server := rcon.NewRCON("", 2301, map[string]rcon.Role{
+		"test": {Name: "admin", Commands: []string{"*"}},
+	})
 
 	server.OnCommand(func(seq uint8, command string, from net.Addr) {
 		fmt.Println("command", command, seq, from.String())
 		resp := rcon.MakeCommandResponsePacket(seq, []byte("echo "+command))
 		server.SendResponse(from, resp)
 
-		server.Broadcast(rcon.MakeServerMessagePacket(server.NextSequenceNumber(), []byte("GLOBAL: echo "+command)))
+		server.BroadcastAsync(rcon.MakeServerMessagePacket(server.NextSequenceNumber(), []byte("GLOBAL: echo "+command)))
 	})
 
 	err := server.ListenAndServe()
@@ -0,0 +1,108 @@
+package rcon
+
+import (
+	"net"
+	"strings"
+)
+
+// Role defines a named set of command verbs a client authenticated with a
+// given password is allowed to run. The special verb "*" allows every command
+type Role struct {
+	Name     string
+	Commands []string
+}
+
+// Allows reports whether the role is allowed to run verb
+func (ro Role) Allows(verb string) bool {
+	for _, allowed := range ro.Commands {
+		if allowed == "*" || allowed == verb {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Command carries everything a Handler or Middleware needs to know about an
+// incoming command
+type Command struct {
+	Seq  uint8  // sequence number of the command packet, used to answer it
+	Verb string // first word of the command, used for routing
+	Args string // remainder of the command, after the verb
+	Raw  string // command exactly as received
+	From net.Addr
+	Role Role // role of the client that issued the command
+}
+
+// Handler handles a single command and returns the response packet(s) to
+// send back to the client, already encoded (see MakeCommandResponsePackets).
+// A nil result means no response is sent
+type Handler func(cmd Command) [][]byte
+
+// Middleware wraps a Handler to add cross-cutting behavior such as logging,
+// rate limiting, metrics or auth checks. It can short-circuit the chain by
+// returning its own response packet(s) directly, without calling next
+type Middleware func(next Handler) Handler
+
+// Mux is a command dispatcher, analogous to http.ServeMux: it routes a
+// command to the Handler registered for its verb (the first word of the
+// command), falling back to a default handler when no verb matches, and
+// wraps every dispatch with the registered Middleware
+type Mux struct {
+	handlers       map[string]Handler
+	defaultHandler Handler
+	middleware     []Middleware
+}
+
+// NewMux creates an empty Mux
+func NewMux() *Mux {
+	return &Mux{handlers: make(map[string]Handler)}
+}
+
+// Handle registers handler to run commands whose verb is verb
+func (m *Mux) Handle(verb string, handler Handler) {
+	m.handlers[verb] = handler
+}
+
+// HandleDefault registers handler to run when no verb matches
+func (m *Mux) HandleDefault(handler Handler) {
+	m.defaultHandler = handler
+}
+
+// Use appends middleware to the chain wrapping every dispatched command,
+// outermost middleware first
+func (m *Mux) Use(middleware ...Middleware) {
+	m.middleware = append(m.middleware, middleware...)
+}
+
+// ServeCommand routes cmd to its registered Handler, wrapped by every
+// registered Middleware, and returns the response packet(s) to send back.
+// It returns nil when no Handler is registered for cmd
+func (m *Mux) ServeCommand(cmd Command) [][]byte {
+	handler, ok := m.handlers[cmd.Verb]
+	if !ok {
+		handler = m.defaultHandler
+	}
+
+	if handler == nil {
+		return nil
+	}
+
+	for i := len(m.middleware) - 1; i >= 0; i-- {
+		handler = m.middleware[i](handler)
+	}
+
+	return handler(cmd)
+}
+
+// splitCommand splits raw into its verb (first word) and the remaining args
+func splitCommand(raw string) (verb string, args string) {
+	raw = strings.TrimSpace(raw)
+
+	idx := strings.IndexByte(raw, ' ')
+	if idx == -1 {
+		return raw, ""
+	}
+
+	return raw[:idx], strings.TrimSpace(raw[idx+1:])
+}
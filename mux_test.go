@@ -0,0 +1,106 @@
+package rcon
+
+import "testing"
+
+func TestRoleAllows(t *testing.T) {
+	t.Run("wildcard allows everything", func(t *testing.T) {
+		role := Role{Commands: []string{"*"}}
+		if !role.Allows("kick") {
+			t.Fatal("expected a wildcard role to allow kick")
+		}
+	})
+
+	t.Run("explicit verb", func(t *testing.T) {
+		role := Role{Commands: []string{"players"}}
+		if !role.Allows("players") {
+			t.Fatal("expected the role to allow players")
+		}
+		if role.Allows("kick") {
+			t.Fatal("expected the role to deny kick")
+		}
+	})
+
+	t.Run("zero value denies everything", func(t *testing.T) {
+		var role Role
+		if role.Allows("players") {
+			t.Fatal("expected the zero value role to deny everything")
+		}
+	})
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		raw      string
+		wantVerb string
+		wantArgs string
+	}{
+		{"players", "players", ""},
+		{"kick 1 reason", "kick", "1 reason"},
+		{"  kick   1  ", "kick", "1"},
+	}
+
+	for _, tt := range tests {
+		verb, args := splitCommand(tt.raw)
+		if verb != tt.wantVerb || args != tt.wantArgs {
+			t.Fatalf("splitCommand(%q) = (%q, %q), want (%q, %q)", tt.raw, verb, args, tt.wantVerb, tt.wantArgs)
+		}
+	}
+}
+
+func TestMuxServeCommand(t *testing.T) {
+	t.Run("routes to registered verb", func(t *testing.T) {
+		mux := NewMux()
+		mux.Handle("players", func(cmd Command) [][]byte {
+			return [][]byte{[]byte(cmd.Args)}
+		})
+
+		got := mux.ServeCommand(Command{Verb: "players", Args: "online"})
+		if len(got) != 1 || string(got[0]) != "online" {
+			t.Fatalf("unexpected result %v", got)
+		}
+	})
+
+	t.Run("falls back to default handler", func(t *testing.T) {
+		mux := NewMux()
+		mux.HandleDefault(func(cmd Command) [][]byte {
+			return [][]byte{[]byte("default")}
+		})
+
+		got := mux.ServeCommand(Command{Verb: "unknown"})
+		if len(got) != 1 || string(got[0]) != "default" {
+			t.Fatalf("unexpected result %v", got)
+		}
+	})
+
+	t.Run("no handler registered returns nil", func(t *testing.T) {
+		if got := NewMux().ServeCommand(Command{Verb: "unknown"}); got != nil {
+			t.Fatalf("expected nil, got %v", got)
+		}
+	})
+
+	t.Run("middleware can short-circuit the chain", func(t *testing.T) {
+		mux := NewMux()
+		mux.Handle("kick", func(cmd Command) [][]byte {
+			return [][]byte{[]byte("kicked")}
+		})
+
+		mux.Use(func(next Handler) Handler {
+			return func(cmd Command) [][]byte {
+				if cmd.Verb == "kick" && !cmd.Role.Allows("kick") {
+					return [][]byte{[]byte("denied")}
+				}
+				return next(cmd)
+			}
+		})
+
+		denied := mux.ServeCommand(Command{Verb: "kick", Role: Role{}})
+		if len(denied) != 1 || string(denied[0]) != "denied" {
+			t.Fatalf("expected middleware to short-circuit, got %v", denied)
+		}
+
+		allowed := mux.ServeCommand(Command{Verb: "kick", Role: Role{Commands: []string{"*"}}})
+		if len(allowed) != 1 || string(allowed[0]) != "kicked" {
+			t.Fatalf("expected the handler to run, got %v", allowed)
+		}
+	})
+}
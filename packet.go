@@ -17,7 +17,28 @@ const (
 	ServerMessagePacketType = PacketType(0x02) // ServerMessagePacketType used when the server broadcast a packet for the client
 	UnknownPacketType       = PacketType(0xff) // UnknownPacketType when the application cannot figure out the packet type
 
-	FragmentationPacketType = PacketType(0x00) // FragmentationPacketType, a packet used when the server response has a lot of data, we dont implement it
+	FragmentationPacketType = PacketType(0x00) // FragmentationPacketType, the sub-type a CommandResponsePacket carries right after the sequence number when the response had to be split across multiple packets
+)
+
+const (
+	// maxResponsePacketSize is the largest single UDP datagram we will send
+	// for a command response, matching the ~4096 byte limit commonly used by
+	// BattlEye RCon servers.
+	maxResponsePacketSize = 4096
+
+	// maxSingleResponsePayloadSize is how much payload fits in a single,
+	// non-fragmented CommandResponsePacket once the packet header and the
+	// CommandPacketType/sequence bytes are accounted for.
+	maxSingleResponsePayloadSize = maxResponsePacketSize - 7 - 2
+
+	// maxFragmentPayloadSize is how much payload fits in a single fragment
+	// once the packet header, the CommandPacketType/sequence bytes and the
+	// FragmentationPacketType/total/index bytes are accounted for.
+	maxFragmentPayloadSize = maxResponsePacketSize - 7 - 2 - 3
+
+	// maxFragments is the largest number of fragments we can describe, since
+	// the total fragment count is encoded as a single byte on the wire.
+	maxFragments = 255
 )
 
 // Stringer returns the string representation for the packet
@@ -129,6 +150,31 @@ func ParseSequenceNumber(input io.Reader) (byte, error) {
 	return seq, nil
 }
 
+// FragmentHeader carries the extra bookkeeping found right after the
+// FragmentationPacketType sub-type byte on a fragmented CommandResponsePacket
+type FragmentHeader struct {
+	Total uint8 // total number of packets making up the response
+	Index uint8 // 0-indexed number of this packet
+}
+
+// ParseFragmentHeader parses the fragment total/index bytes that follow the
+// FragmentationPacketType sub-type byte of a fragmented CommandResponsePacket
+func ParseFragmentHeader(input io.Reader) (FragmentHeader, error) {
+	var fh FragmentHeader
+
+	err := binary.Read(input, binary.LittleEndian, &fh.Total)
+	if err != nil {
+		return fh, errors.Wrap(err, "could not parse fragment total")
+	}
+
+	err = binary.Read(input, binary.LittleEndian, &fh.Index)
+	if err != nil {
+		return fh, errors.Wrap(err, "could not parse fragment index")
+	}
+
+	return fh, nil
+}
+
 // ParseCommand tries to read the rest of the input and extract an command or string
 func ParseCommand(input io.Reader) (string, error) {
 	buf := make([]byte, 4098)
@@ -155,6 +201,42 @@ func MakeLoginResponsePacket(responseType LoginResponseType) []byte {
 	return output.Bytes()
 }
 
+// MakeLoginRequestPacket creates a new login request packet carrying password,
+// already encoded as byte slice. This is what a client sends to start the
+// login handshake, the counterpart of MakeLoginResponsePacket
+func MakeLoginRequestPacket(password string) []byte {
+	var payload bytes.Buffer
+	payload.WriteByte(byte(LoginPacketType))
+	payload.WriteString(password)
+
+	header := NewPacketHeader(NewChecksum(append([]byte{0xff}, payload.Bytes()...)))
+
+	var output bytes.Buffer
+	output.Write(header.Encode())
+	output.Write(payload.Bytes())
+
+	return output.Bytes()
+}
+
+// MakeCommandRequestPacket creates a new command request packet carrying
+// command, already encoded as byte slice. This is what a client sends to run
+// a command on the server, use an empty command for the keep-alive packet
+// the protocol requires every 45 seconds
+func MakeCommandRequestPacket(seq uint8, command string) []byte {
+	var payload bytes.Buffer
+	payload.WriteByte(byte(CommandPacketType))
+	payload.WriteByte(byte(seq))
+	payload.WriteString(command)
+
+	header := NewPacketHeader(NewChecksum(append([]byte{0xff}, payload.Bytes()...)))
+
+	var output bytes.Buffer
+	output.Write(header.Encode())
+	output.Write(payload.Bytes())
+
+	return output.Bytes()
+}
+
 // MakeCommandResponsePacket creates a new CommandResponsePacket encoded as byte slice
 // Note that you should provide the sequence number for the command that you are "answering"
 // the data is the payload that you want to send
@@ -176,6 +258,61 @@ func MakeCommandResponsePacket(seq uint8, data []byte) []byte {
 	return output.Bytes()
 }
 
+// MakeCommandResponsePackets creates the CommandResponsePacket(s) needed to answer
+// seq with data, already encoded as byte slices and ready to be sent in order.
+// When data fits in a single UDP datagram it behaves like MakeCommandResponsePacket
+// and returns a single packet. Otherwise data is split across multiple fragments,
+// each carrying the FragmentationPacketType sub-type, the total number of
+// fragments and its own 0-indexed fragment number, as described by the
+// BattlEye RCon protocol spec. It returns an error if data would need more
+// than maxFragments fragments, since the total fragment count is encoded as a
+// single byte on the wire and would otherwise wrap and corrupt reassembly.
+func MakeCommandResponsePackets(seq uint8, data []byte) ([][]byte, error) {
+	if len(data) <= maxSingleResponsePayloadSize {
+		return [][]byte{MakeCommandResponsePacket(seq, data)}, nil
+	}
+
+	var chunks [][]byte
+	for len(data) > 0 {
+		n := maxFragmentPayloadSize
+		if n > len(data) {
+			n = len(data)
+		}
+
+		chunks = append(chunks, data[:n])
+		data = data[n:]
+	}
+
+	if len(chunks) > maxFragments {
+		return nil, errors.Errorf("data needs %d fragments, more than the %d the protocol can address", len(chunks), maxFragments)
+	}
+
+	packets := make([][]byte, len(chunks))
+	for i, chunk := range chunks {
+		packets[i] = makeFragmentedCommandResponsePacket(seq, uint8(len(chunks)), uint8(i), chunk)
+	}
+
+	return packets, nil
+}
+
+func makeFragmentedCommandResponsePacket(seq uint8, total uint8, index uint8, data []byte) []byte {
+	var payload bytes.Buffer
+	payload.WriteByte(byte(CommandPacketType))
+	payload.WriteByte(byte(seq))
+	payload.WriteByte(byte(FragmentationPacketType))
+	payload.WriteByte(total)
+	payload.WriteByte(index)
+	payload.Write(data)
+
+	header := NewPacketHeader(NewChecksum(append([]byte{0xff}, payload.Bytes()...)))
+
+	var output bytes.Buffer
+	output.Write(header.Encode())
+	output.Write(payload.Bytes())
+
+	return output.Bytes()
+}
+
 // MakeServerMessagePacket creates a new ServerMessagePacket and encode it as a byte slice
 // You must provide an sequence number, you can get an valid sequence number from the RCON struct
 // calling the NextSequenceNumber, the data is the payload that you want to send
@@ -84,3 +84,101 @@ func TestParseHeader(t *testing.T) {
 		}
 	})
 }
+
+func TestMakeCommandResponsePackets(t *testing.T) {
+	t.Run("fits in a single packet", func(t *testing.T) {
+		data := []byte("hello world")
+
+		packets, err := MakeCommandResponsePackets(42, data)
+		if err != nil {
+			t.Fatalf("unexpected error, %v", err)
+		}
+		if len(packets) != 1 {
+			t.Fatalf("expected a single packet, got %d", len(packets))
+		}
+
+		if !bytes.Equal(packets[0], MakeCommandResponsePacket(42, data)) {
+			t.Fatalf("expected single packet to match MakeCommandResponsePacket output")
+		}
+	})
+
+	t.Run("round-trips a response bigger than one datagram", func(t *testing.T) {
+		data := make([]byte, 12000)
+		for i := range data {
+			data[i] = byte(i % 256)
+		}
+
+		seq := uint8(7)
+		packets, err := MakeCommandResponsePackets(seq, data)
+		if err != nil {
+			t.Fatalf("unexpected error, %v", err)
+		}
+		if len(packets) < 2 {
+			t.Fatalf("expected response to be split across multiple packets, got %d", len(packets))
+		}
+
+		assembled := make(map[uint8][]byte)
+		var total uint8
+
+		for _, packet := range packets {
+			input := bytes.NewReader(packet)
+
+			if _, err := ParseHeader(input); err != nil {
+				t.Fatalf("could not parse header, %v", err)
+			}
+
+			packetType, err := ParsePacketType(input)
+			if err != nil || packetType != CommandPacketType {
+				t.Fatalf("expected CommandPacketType, got %v, err %v", packetType, err)
+			}
+
+			gotSeq, err := ParseSequenceNumber(input)
+			if err != nil || gotSeq != seq {
+				t.Fatalf("expected sequence %d, got %d, err %v", seq, gotSeq, err)
+			}
+
+			subType, err := ParsePacketType(input)
+			if err != nil || subType != FragmentationPacketType {
+				t.Fatalf("expected FragmentationPacketType, got %v, err %v", subType, err)
+			}
+
+			fragHeader, err := ParseFragmentHeader(input)
+			if err != nil {
+				t.Fatalf("could not parse fragment header, %v", err)
+			}
+			total = fragHeader.Total
+
+			chunk, err := ParseCommand(input)
+			if err != nil {
+				t.Fatalf("could not parse fragment chunk, %v", err)
+			}
+
+			assembled[fragHeader.Index] = []byte(chunk)
+		}
+
+		if int(total) != len(packets) {
+			t.Fatalf("expected total of %d, got %d", len(packets), total)
+		}
+
+		var full bytes.Buffer
+		for i := uint8(0); i < total; i++ {
+			full.Write(assembled[i])
+		}
+
+		if !bytes.Equal(full.Bytes(), data) {
+			t.Fatalf("reassembled payload did not match original data")
+		}
+	})
+
+	t.Run("errors instead of wrapping the fragment total past 255", func(t *testing.T) {
+		data := make([]byte, (maxFragments+1)*maxFragmentPayloadSize)
+
+		packets, err := MakeCommandResponsePackets(7, data)
+		if err == nil {
+			t.Fatal("expected an error for a payload needing more than 255 fragments")
+		}
+		if packets != nil {
+			t.Fatalf("expected no packets on error, got %d", len(packets))
+		}
+	})
+}
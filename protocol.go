@@ -14,11 +14,13 @@ import (
 	"net"
 	"os"
 	"strconv"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
 	"go.uber.org/atomic"
+
+	"github.com/crossworth/battleye-rcon/events"
 )
 
 const (
@@ -36,38 +38,75 @@ const (
 	// used for internal implementation
 	rconServerMessageTries       = 5
 	rconServerMessageRetryPeriod = 10 * time.Second
+
+	// how long we keep partially received fragments around waiting for the
+	// remaining packets before giving up on reassembling them
+	fragmentTimeout = 15 * time.Second
 )
 
+// fragmentBuffer accumulates the fragments of a single fragmented
+// CommandResponsePacket until every one of them has arrived
+type fragmentBuffer struct {
+	total  uint8
+	chunks map[uint8][]byte
+}
+
+// clientInfo is what we keep in the clients cache for every authenticated client
+type clientInfo struct {
+	addr net.Addr
+	role Role
+}
+
+// ackKey identifies the ServerMessagePacketType acknowledge a Broadcast
+// attempt is waiting for
+type ackKey struct {
+	addr string
+	seq  uint8
+}
+
+// BroadcastResult reports the outcome of delivering a Broadcast message to a
+// single client
+type BroadcastResult struct {
+	Addr      net.Addr
+	Delivered bool
+	Attempts  int
+	Latency   time.Duration
+}
+
 // RCON is the RCON server implementation
 // it manages state about the clients, blocked and banned ips
 // and server messages acknowledges.
 type RCON struct {
-	conn                      *net.UDPConn
-	host                      string
-	port                      int
-	password                  string
-	commandHandler            func(seq uint8, command string, from net.Addr)
-	clients                   *cache.Cache
-	blockedIp                 *cache.Cache
-	wrongPasswordCounter      *cache.Cache
-	ipBanList                 []string
-	Logger                    Logger
-	seqNumber                 atomic.Uint32
-	serverMessageAcknowledges *cache.Cache
+	conn                 *net.UDPConn
+	host                 string
+	port                 int
+	passwords            map[string]Role
+	eventHandler         func(event events.Event)
+	mux                  *Mux
+	clients              *cache.Cache
+	blockedIp            *cache.Cache
+	wrongPasswordCounter *cache.Cache
+	ipBanList            []string
+	Logger               Logger
+	seqNumber            atomic.Uint32
+	acks                 sync.Map
+	fragmentBuffers      *cache.Cache
 }
 
-// NewRCON create a new RCON server with the host (IP/interface), port
-// and password provided, it will not start the server.
-func NewRCON(host string, port int, password string) *RCON {
+// NewRCON create a new RCON server with the host (IP/interface) and port
+// provided. passwords maps each accepted password to the Role granted to
+// clients authenticating with it, it will not start the server.
+func NewRCON(host string, port int, passwords map[string]Role) *RCON {
 	rcon := &RCON{
-		host:                      host,
-		port:                      port,
-		password:                  password,
-		clients:                   cache.New(cache.DefaultExpiration, 10*time.Minute),
-		blockedIp:                 cache.New(cache.DefaultExpiration, 10*time.Minute),
-		wrongPasswordCounter:      cache.New(cache.DefaultExpiration, 10*time.Minute),
-		Logger:                    log.New(os.Stdout, "", log.LstdFlags),
-		serverMessageAcknowledges: cache.New(cache.DefaultExpiration, 1*time.Minute),
+		host:                 host,
+		port:                 port,
+		passwords:            passwords,
+		mux:                  NewMux(),
+		clients:              cache.New(cache.DefaultExpiration, 10*time.Minute),
+		blockedIp:            cache.New(cache.DefaultExpiration, 10*time.Minute),
+		wrongPasswordCounter: cache.New(cache.DefaultExpiration, 10*time.Minute),
+		Logger:               log.New(os.Stdout, "", log.LstdFlags),
+		fragmentBuffers:      cache.New(cache.DefaultExpiration, 1*time.Minute),
 	}
 
 	rcon.seqNumber.Store(0)
@@ -80,9 +119,28 @@ func (r *RCON) SetIPBanList(ipBanList []string) {
 	r.ipBanList = ipBanList
 }
 
-// OnCommand can be used to register a callback to be executed once the server receives a command
+// OnCommand registers handle to run for every command, as a thin shim over
+// Mux.HandleDefault kept for backwards compatibility. New code should use Mux
+// (see RCON.Mux) to route commands per verb and attach Middleware
 func (r *RCON) OnCommand(handle func(seq uint8, command string, from net.Addr)) {
-	r.commandHandler = handle
+	r.mux.HandleDefault(func(cmd Command) [][]byte {
+		handle(cmd.Seq, cmd.Raw, cmd.From)
+		return nil
+	})
+}
+
+// Mux returns the command dispatcher used to route incoming commands, use it
+// to register per-verb Handlers with Mux.Handle and Middleware with Mux.Use
+func (r *RCON) Mux() *Mux {
+	return r.mux
+}
+
+// OnEvent can be used to register a callback executed with the parsed events.Event
+// every time Broadcast sends out a non-empty message, so bots built on top of
+// this library can react to well-known BattlEye server events without
+// regex-scraping. Messages that match no known format arrive as events.Unknown
+func (r *RCON) OnEvent(handle func(event events.Event)) {
+	r.eventHandler = handle
 }
 
 func (r *RCON) addressInBanList(addr string) bool {
@@ -124,15 +182,17 @@ func (r *RCON) Clients() []net.Addr {
 	var clients []net.Addr
 
 	for _, c := range r.clients.Items() {
-		clients = append(clients, c.Object.(net.Addr))
+		clients = append(clients, c.Object.(clientInfo).addr)
 	}
 
 	return clients
 }
 
-// Broadcast send an message to all the connected clients
-// it will handle the retry and acknowledgement of messages
-func (r *RCON) Broadcast(data []byte) {
+// Broadcast sends data to every connected client, retrying delivery until
+// each one acknowledges it or we run out of attempts. It returns a channel
+// carrying one BroadcastResult per client, closed once every client has
+// been attempted.
+func (r *RCON) Broadcast(data []byte) <-chan BroadcastResult {
 	// we need the sequence, so we
 	// have to parse the data first
 	input := bytes.NewReader(data)
@@ -140,35 +200,83 @@ func (r *RCON) Broadcast(data []byte) {
 	_, _ = ParsePacketType(input)
 	seq, _ := ParseSequenceNumber(input)
 
-	for _, addr := range r.Clients() {
-		go func(addr net.Addr, seq uint8) {
+	if r.eventHandler != nil {
+		if message, err := ParseCommand(input); err == nil && message != "" {
+			r.eventHandler(events.Parse(message))
+		}
+	}
 
-			receivedAcknowledge := false
+	clients := r.Clients()
+	results := make(chan BroadcastResult, len(clients))
 
-			for i := 0; i < rconServerMessageTries; i++ {
-				_ = r.SendResponse(addr, data)
-				time.Sleep(time.Duration(rconServerMessageRetryPeriod.Seconds()/rconServerMessageTries) * time.Second)
+	var wg sync.WaitGroup
+	for _, addr := range clients {
+		wg.Add(1)
+		go func(addr net.Addr) {
+			defer wg.Done()
+			results <- r.broadcastToClient(addr, seq, data)
+		}(addr)
+	}
 
-				// check for acknowledge
-				for addrAndSeq := range r.serverMessageAcknowledges.Items() {
-					parts := strings.Split(addrAndSeq, "_")
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 
-					if parts[0] == addr.String() && strconv.Itoa(int(seq)) == parts[1] {
-						receivedAcknowledge = true
-						r.serverMessageAcknowledges.Delete(addrAndSeq)
-					}
-				}
+	return results
+}
 
-				if receivedAcknowledge {
-					break
-				}
-			}
+// BroadcastAsync sends data to every connected client without waiting for
+// delivery, keeping the fire-and-forget behavior Broadcast used to have
+func (r *RCON) BroadcastAsync(data []byte) {
+	go func() {
+		for range r.Broadcast(data) {
+		}
+	}()
+}
 
-			// could not write to client or received an acknowledge, remove it from list
-			if !receivedAcknowledge {
-				r.clients.Delete(addr.String())
-			}
-		}(addr, seq)
+// broadcastToClient sends data to addr, retrying until it is acknowledged
+// (see acknowledge) or we run out of attempts, removing addr from the
+// client list on failure.
+func (r *RCON) broadcastToClient(addr net.Addr, seq uint8, data []byte) BroadcastResult {
+	result := BroadcastResult{Addr: addr}
+
+	key := ackKey{addr: addr.String(), seq: seq}
+	ack := make(chan struct{})
+	r.acks.Store(key, ack)
+	defer r.acks.Delete(key)
+
+	retryInterval := rconServerMessageRetryPeriod / rconServerMessageTries
+
+	start := time.Now()
+
+	for i := 0; i < rconServerMessageTries; i++ {
+		result.Attempts++
+		_ = r.SendResponse(addr, data)
+
+		select {
+		case <-ack:
+			result.Delivered = true
+			result.Latency = time.Since(start)
+			return result
+		case <-time.After(retryInterval):
+		}
+	}
+
+	// never got an acknowledge, remove the client from the list
+	r.clients.Delete(addr.String())
+	result.Latency = time.Since(start)
+
+	return result
+}
+
+// acknowledge notifies any in-flight broadcastToClient waiting on seq for
+// addr that its ServerMessagePacketType was received
+func (r *RCON) acknowledge(addr net.Addr, seq uint8) {
+	key := ackKey{addr: addr.String(), seq: seq}
+
+	if ack, found := r.acks.LoadAndDelete(key); found {
+		close(ack.(chan struct{}))
 	}
 }
 
@@ -182,6 +290,24 @@ func (r *RCON) SendResponse(to net.Addr, data []byte) error {
 	return err
 }
 
+// SendCommandResponse answers seq with data, transparently splitting data
+// across multiple fragmented packets (see MakeCommandResponsePackets) when it
+// does not fit in a single UDP datagram.
+func (r *RCON) SendCommandResponse(to net.Addr, seq uint8, data []byte) error {
+	packets, err := MakeCommandResponsePackets(seq, data)
+	if err != nil {
+		return err
+	}
+
+	for _, packet := range packets {
+		if err := r.SendResponse(to, packet); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // ListenAndServe start the server on the udp ip/port provided and
 // start handling packets
 func (r *RCON) ListenAndServe() error {
@@ -259,7 +385,8 @@ func (r *RCON) handlePacket(addr net.Addr, data []byte) {
 	}
 
 	// Refresh client keep alive
-	r.clients.Set(addr.String(), addr, keepAliveCheck)
+	info, _ := r.clients.Get(addr.String())
+	r.clients.Set(addr.String(), info.(clientInfo), keepAliveCheck)
 
 	if packetType == CommandPacketType {
 		seq, err := ParseSequenceNumber(input)
@@ -275,6 +402,33 @@ func (r *RCON) handlePacket(addr net.Addr, data []byte) {
 			return
 		}
 
+		// if the next byte is the FragmentationPacketType sub-type we are
+		// looking at one fragment of a multi-packet response, reassemble it
+		// before handing a complete command over to the handler
+		if data[len(data)-input.Len()] == byte(FragmentationPacketType) {
+			_, _ = ParsePacketType(input)
+
+			fragHeader, err := ParseFragmentHeader(input)
+			if err != nil {
+				r.Logger.Printf("%s: error reading fragment header, %v\n", addr.String(), err)
+				return
+			}
+
+			chunk, err := ParseCommand(input)
+			if err != nil {
+				r.Logger.Printf("%s: error reading fragment chunk, %v\n", addr.String(), err)
+				return
+			}
+
+			command, complete := r.bufferFragment(addr, seq, fragHeader, chunk)
+			if !complete {
+				return
+			}
+
+			r.dispatchCommand(addr, seq, command)
+			return
+		}
+
 		// we have something to read, must be a command
 		command, err := ParseCommand(input)
 		if err != nil {
@@ -282,11 +436,7 @@ func (r *RCON) handlePacket(addr net.Addr, data []byte) {
 			return
 		}
 
-		// if an command handler was defined we pass to it
-		if r.commandHandler != nil {
-			r.commandHandler(seq, command, addr)
-			return
-		}
+		r.dispatchCommand(addr, seq, command)
 	}
 
 	if packetType == ServerMessagePacketType {
@@ -296,11 +446,66 @@ func (r *RCON) handlePacket(addr net.Addr, data []byte) {
 			return
 		}
 
-		r.serverMessageAcknowledges.Set(addr.String()+"_"+strconv.Itoa(int(seq)), true, 15*time.Second)
+		r.acknowledge(addr, seq)
 		return
 	}
 }
 
+// bufferFragment stores a single fragment of a fragmented CommandPacketType
+// response, keyed by the client address and command sequence number. It
+// returns the reassembled command and true once every fragment described by
+// fragHeader has arrived.
+func (r *RCON) bufferFragment(addr net.Addr, seq uint8, fragHeader FragmentHeader, chunk string) (string, bool) {
+	key := addr.String() + "_" + strconv.Itoa(int(seq))
+
+	var fb *fragmentBuffer
+	if existing, found := r.fragmentBuffers.Get(key); found {
+		fb = existing.(*fragmentBuffer)
+	} else {
+		fb = &fragmentBuffer{total: fragHeader.Total, chunks: make(map[uint8][]byte)}
+	}
+
+	fb.chunks[fragHeader.Index] = []byte(chunk)
+
+	if len(fb.chunks) < int(fb.total) {
+		r.fragmentBuffers.Set(key, fb, fragmentTimeout)
+		return "", false
+	}
+
+	r.fragmentBuffers.Delete(key)
+
+	var full bytes.Buffer
+	for i := uint8(0); i < fb.total; i++ {
+		full.Write(fb.chunks[i])
+	}
+
+	return full.String(), true
+}
+
+// dispatchCommand enforces the requesting client's Role and, when allowed,
+// routes command to the Mux, sending back whatever response packet(s) the
+// Handler/Middleware chain returns
+func (r *RCON) dispatchCommand(addr net.Addr, seq uint8, command string) {
+	info, _ := r.clients.Get(addr.String())
+	client, _ := info.(clientInfo)
+
+	verb, args := splitCommand(command)
+
+	if !client.role.Allows(verb) {
+		r.Logger.Printf("%s: not allowed to run %q\n", addr.String(), verb)
+		_ = r.SendCommandResponse(addr, seq, []byte("Permission denied"))
+		return
+	}
+
+	cmd := Command{Seq: seq, Verb: verb, Args: args, Raw: command, From: addr, Role: client.role}
+
+	for _, packet := range r.mux.ServeCommand(cmd) {
+		if err := r.SendResponse(addr, packet); err != nil {
+			r.Logger.Printf("%s: could not write to connection, %v\n", addr.String(), err)
+		}
+	}
+}
+
 func (r *RCON) handleKeepAlive(addr net.Addr, seq byte) {
 	err := r.SendResponse(addr, MakeCommandResponsePacket(seq, []byte{}))
 	if err != nil {
@@ -311,7 +516,9 @@ func (r *RCON) handleKeepAlive(addr net.Addr, seq byte) {
 func (r *RCON) handleAuthentication(addr net.Addr, password string) {
 	clientIP := addressWithoutPort(addr.String())
 
-	if password != r.password {
+	role, ok := r.passwords[password]
+
+	if !ok {
 		err := r.SendResponse(addr, MakeLoginResponsePacket(LoginFailed))
 
 		if err != nil {
@@ -331,10 +538,12 @@ func (r *RCON) handleAuthentication(addr net.Addr, password string) {
 			r.blockedIp.Set(clientIP, true, blockIpInterval)
 			r.Logger.Printf("%s: ip blocked for %s, reached maximum tries\n", addr.String(), blockIpInterval.String())
 		}
+
+		return
 	}
 
 	log.Printf("%s: authenticated with password\n", addr.String())
-	r.clients.Set(addr.String(), addr, keepAliveCheck)
+	r.clients.Set(addr.String(), clientInfo{addr: addr, role: role}, keepAliveCheck)
 
 	err := r.SendResponse(addr, MakeLoginResponsePacket(LoginSuccessful))
 	if err != nil {
@@ -0,0 +1,141 @@
+package rcon
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestRCON(t *testing.T, passwords map[string]Role) *RCON {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	r := NewRCON("127.0.0.1", 0, passwords)
+	r.conn = conn
+
+	return r
+}
+
+func TestRCONHandleAuthenticationFailureDoesNotRegisterClient(t *testing.T) {
+	r := newTestRCON(t, map[string]Role{"secret": {Commands: []string{"*"}}})
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1}
+
+	r.handleAuthentication(addr, "wrong")
+
+	if _, found := r.clients.Get(addr.String()); found {
+		t.Fatal("expected a client with the wrong password to not be registered")
+	}
+}
+
+func TestRCONHandleAuthenticationSuccessRegistersClient(t *testing.T) {
+	r := newTestRCON(t, map[string]Role{"secret": {Commands: []string{"*"}}})
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1}
+
+	r.handleAuthentication(addr, "secret")
+
+	info, found := r.clients.Get(addr.String())
+	if !found {
+		t.Fatal("expected the client to be registered after a successful login")
+	}
+
+	if info.(clientInfo).role.Commands[0] != "*" {
+		t.Fatalf("expected the client to be registered with the role granted by its password, got %#v", info)
+	}
+}
+
+func TestRCONAcknowledgeUnblocksBroadcastToClient(t *testing.T) {
+	r := newTestRCON(t, nil)
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1}
+
+	key := ackKey{addr: addr.String(), seq: 7}
+	ack := make(chan struct{})
+	r.acks.Store(key, ack)
+
+	r.acknowledge(addr, 7)
+
+	select {
+	case <-ack:
+	default:
+		t.Fatal("expected the ack channel to be closed")
+	}
+
+	if _, found := r.acks.Load(key); found {
+		t.Fatal("expected the ack to be removed from the wait map after acknowledge")
+	}
+}
+
+func TestRCONAcknowledgeWithNoInFlightBroadcastIsANoop(t *testing.T) {
+	r := newTestRCON(t, nil)
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1}
+
+	// should not panic when there is no broadcastToClient waiting on this seq
+	r.acknowledge(addr, 1)
+}
+
+func TestRCONBufferFragment(t *testing.T) {
+	r := newTestRCON(t, nil)
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1}
+
+	if _, complete := r.bufferFragment(addr, 5, FragmentHeader{Total: 2, Index: 0}, "AAAA"); complete {
+		t.Fatal("expected incomplete after the first of two fragments")
+	}
+
+	command, complete := r.bufferFragment(addr, 5, FragmentHeader{Total: 2, Index: 1}, "BBBB")
+	if !complete {
+		t.Fatal("expected complete after the second of two fragments")
+	}
+
+	if command != "AAAABBBB" {
+		t.Fatalf("expected reassembled command %q, got %q", "AAAABBBB", command)
+	}
+}
+
+func TestRCONFragmentBuffersExpireOrphanedFragments(t *testing.T) {
+	r := newTestRCON(t, nil)
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1}
+	key := addr.String() + "_5"
+
+	// simulate a response that was never finished: only the first of two
+	// fragments ever arrived, with a short TTL so we don't have to wait
+	// fragmentTimeout out in the test
+	r.fragmentBuffers.Set(key, &fragmentBuffer{total: 2, chunks: map[uint8][]byte{0: []byte("AAAA")}}, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	if _, found := r.fragmentBuffers.Get(key); found {
+		t.Fatal("expected the orphaned fragment buffer to expire instead of lingering across a sequence number wraparound")
+	}
+
+	command, complete := r.bufferFragment(addr, 5, FragmentHeader{Total: 2, Index: 1}, "BBBB")
+	if complete && command == "AAAABBBB" {
+		t.Fatal("expected the expired fragment to not be mixed into an unrelated response reusing the same sequence number")
+	}
+}
+
+func TestRCONDispatchCommandACL(t *testing.T) {
+	r := newTestRCON(t, nil)
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1}
+
+	var ran bool
+	r.Mux().Handle("players", func(cmd Command) [][]byte {
+		ran = true
+		return nil
+	})
+
+	r.clients.Set(addr.String(), clientInfo{addr: addr, role: Role{Commands: []string{"players"}}}, time.Minute)
+
+	r.dispatchCommand(addr, 1, "players")
+	if !ran {
+		t.Fatal("expected the players handler to run for an allowed verb")
+	}
+
+	ran = false
+	r.dispatchCommand(addr, 1, "kick 1")
+	if ran {
+		t.Fatal("expected kick to be denied by the client's role")
+	}
+}